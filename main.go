@@ -1,33 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"go/build"
 	"log"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
-	"golang.org/x/mod/modfile"
+	"github.com/lotusirous/godepgraph/pkg/depgraph"
+	"github.com/lotusirous/godepgraph/pkg/render"
 )
 
 var (
-	pkgs            = make(map[string]*build.Package)
-	erroredPkgs     = make(map[string]bool)
-	ids             = make(map[string]string)
-	module          = ""
-	cwd             = ""
-	requiredModules = make([]string, 0)
-
-	ignoreModFile = flag.Bool("mod", true, "use the mod file")
-	stopOnError   = flag.Bool("stoponerror", true, "stop on package import errors")
-	horizontal    = flag.Bool("horizontal", false, "lay out the dependency graph horizontally instead of vertically")
-	withTests     = flag.Bool("withtests", false, "include test packages")
-	maxLevel      = flag.Int("maxlevel", 256, "max level of go dependency graph")
-
-	buildContext = build.Default
+	ignoreModFile  = flag.Bool("mod", true, "use the mod file")
+	stopOnError    = flag.Bool("stoponerror", true, "stop on package import errors")
+	horizontal     = flag.Bool("horizontal", false, "lay out the dependency graph horizontally instead of vertically")
+	withTests      = flag.Bool("withtests", false, "include test packages")
+	maxLevel       = flag.Int("maxlevel", 256, "max level of go dependency graph")
+	modClusters    = flag.Bool("modclusters", false, "group each workspace module's packages into its own DOT subgraph cluster")
+	format         = flag.String("format", "dot", "output format: dot, json, mermaid, graphml, d2")
+	failOnCycle    = flag.Bool("failoncycle", false, "exit with a non-zero status if an import cycle is found")
+	collapseCycles = flag.Bool("collapsecycles", false, "render each import cycle as a single collapsed DOT node")
 )
 
 func init() {
@@ -51,171 +46,200 @@ func main() {
 	if len(args) < 1 {
 		log.Fatal("need one package name to process")
 	}
-	cwd = mustGetCwd()
-	module, requiredModules = mustParseModFile()
-	for _, a := range args {
-		if err := processPackage(cwd, a, 0, "", *stopOnError); err != nil {
-			log.Fatal(err)
-		}
-	}
 
-	fmt.Println("digraph godep {")
-	if *horizontal {
-		fmt.Println(`rankdir="LR"`)
+	opts := depgraph.GraphOptions{
+		Dir:          mustGetCwd(),
+		IncludeTests: *withTests,
+		MaxLevel:     *maxLevel,
+		StopOnError:  *stopOnError,
+		IgnoreStdlib: true,
 	}
-	fmt.Print(`splines=spline
-nodesep=0.4
-ranksep=0.8
-node [shape="box",style="rounded,filled"]
-edge [arrowsize="0.5"]
-`)
+	opts.HighlightModules, opts.WorkspaceModules, opts.WorkspaceDirs = resolveModules(opts.Dir)
 
-	// sort packages
-	pkgKeys := []string{}
-	for k := range pkgs {
-		pkgKeys = append(pkgKeys, k)
+	graph, err := depgraph.Build(context.Background(), args, opts)
+	if err != nil {
+		log.Fatal(err)
 	}
-	sort.Strings(pkgKeys)
 
-	for _, pkgName := range pkgKeys {
-		pkg := pkgs[pkgName]
-		pkgId := getId(pkgName)
+	cycles := depgraph.FindCycles(graph)
+	for _, c := range cycles {
+		fmt.Fprintf(os.Stderr, "import cycle: %s\n", c)
+	}
+	if *failOnCycle && len(cycles) > 0 {
+		os.Exit(1)
+	}
 
-		if isIgnored(pkg) {
-			continue
+	if *collapseCycles {
+		if *format != "dot" {
+			log.Fatalf("-collapsecycles is only supported with -format=dot")
 		}
+		printDOTCondensed(graph, cycles, *horizontal)
+		return
+	}
 
-		color := nodeColor(pkg)
-		fmt.Printf("%s [label=\"%s\" color=\"%s\" target=\"_blank\"];\n", pkgId, pkgName, color)
-
-		for _, imp := range getImports(pkg) {
-			impPkg := pkgs[imp]
-			if impPkg == nil || isIgnored(impPkg) {
-				continue
-			}
-
-			impId := getId(imp)
-			fmt.Printf("%s -> %s;\n", pkgId, impId)
+	if *modClusters {
+		if *format != "dot" {
+			log.Fatalf("-modclusters is only supported with -format=dot")
 		}
+		printDOTClusters(graph, opts.WorkspaceModules, *horizontal)
+		return
 	}
-	fmt.Println("}")
-}
 
-func nodeColor(pkg *build.Package) string {
-
-	var color string
-	switch {
-	case pkg.Goroot:
-		color = "palegreen"
-	case len(pkg.CgoFiles) > 0:
-		color = "darkgoldenrod1"
-	case isInModFile(pkg.ImportPath):
-		color = "palegoldenrod"
-	case hasBuildErrors(pkg):
-		color = "red"
-	default:
-		color = "paleturquoise"
-	}
-	return color
-}
-
-func processPackage(root string, pkgName string, level int, importedBy string, stopOnError bool) error {
-	if level++; level > *maxLevel {
-		return nil
+	renderOpts := render.Options{Horizontal: *horizontal}
+	if len(opts.WorkspaceModules) > 0 {
+		renderOpts.Module = opts.WorkspaceModules[0]
 	}
-
-	pkg, buildErr := buildContext.Import(pkgName, root, 0)
-	if buildErr != nil {
-		if stopOnError {
-			return fmt.Errorf("failed to import %s (imported at level %d by %s):\n%s", pkgName, level, importedBy, buildErr)
-		}
+	if err := render.Write(os.Stdout, graph, *format, renderOpts); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	if isIgnored(pkg) {
-		return nil
+// printDOTClusters is the -modclusters DOT variant: it wraps each workspace
+// module's nodes in a DOT subgraph so the rendered graph visually groups
+// packages by their owning module. Packages of other modules are drawn
+// outside any cluster. This predates the pluggable Renderer and stays a
+// one-off because clustering is a DOT-specific visual affordance, not a
+// concept the other formats share.
+func printDOTClusters(graph *depgraph.Graph, workspace []string, horizontal bool) {
+	isMember := make(map[string]bool, len(workspace))
+	for _, m := range workspace {
+		isMember[m] = true
+	}
+
+	ids := make(map[string]string)
+	id := func(name string) string {
+		got, ok := ids[name]
+		if !ok {
+			got = `"` + name + `"`
+			ids[name] = got
+		}
+		return got
 	}
 
-	importPath := pkgName
-	if buildErr != nil {
-		erroredPkgs[importPath] = true
+	fmt.Println("digraph godep {")
+	if horizontal {
+		fmt.Println(`rankdir="LR"`)
 	}
+	fmt.Print(`splines=spline
+nodesep=0.4
+ranksep=0.8
+node [shape="box",style="rounded,filled"]
+edge [arrowsize="0.5"]
+`)
 
-	pkgs[importPath] = pkg
+	clusterOf := make(map[string]int)
+	var nodesByCluster [][]depgraph.Node
+	var unclustered []depgraph.Node
 
-	for _, imp := range getImports(pkg) {
-		if _, ok := pkgs[imp]; !ok {
-			if err := processPackage(pkg.Dir, imp, level, pkgName, stopOnError); err != nil {
-				return err
-			}
+	for _, n := range graph.Nodes {
+		if !isMember[n.Module] {
+			unclustered = append(unclustered, n)
+			continue
 		}
+		i, ok := clusterOf[n.Module]
+		if !ok {
+			i = len(nodesByCluster)
+			clusterOf[n.Module] = i
+			nodesByCluster = append(nodesByCluster, nil)
+		}
+		nodesByCluster[i] = append(nodesByCluster[i], n)
 	}
-	return nil
-}
 
-func getImports(pkg *build.Package) []string {
-	allImports := pkg.Imports
-	if *withTests {
-		allImports = append(allImports, pkg.TestImports...)
-		allImports = append(allImports, pkg.XTestImports...)
-	}
-	var imports []string
-	found := make(map[string]struct{})
-	for _, imp := range allImports {
-		if imp == pkg.ImportPath {
-			// Don't draw a self-reference when foo_test depends on foo.
-			continue
+	for i, nodes := range nodesByCluster {
+		fmt.Printf("subgraph cluster_%d {\nlabel=\"%s\";\n", i, nodes[0].Module)
+		for _, n := range nodes {
+			fmt.Printf("%s [label=\"%s\" color=\"%s\" target=\"_blank\"];\n", id(n.ImportPath), n.ImportPath, n.Color)
 		}
-		if _, ok := found[imp]; ok {
+		fmt.Println("}")
+	}
+	for _, n := range unclustered {
+		fmt.Printf("%s [label=\"%s\" color=\"%s\" target=\"_blank\"];\n", id(n.ImportPath), n.ImportPath, n.Color)
+	}
+	for _, e := range graph.Edges {
+		if e.TestOnly {
+			fmt.Printf("%s -> %s [style=\"dashed\"];\n", id(e.From), id(e.To))
 			continue
 		}
-		found[imp] = struct{}{}
-		imports = append(imports, imp)
+		fmt.Printf("%s -> %s;\n", id(e.From), id(e.To))
 	}
-	return imports
-}
-
-func deriveNodeID(packageName string) string {
-	//TODO: improve implementation?
-	id := "\"" + packageName + "\""
-	return id
+	fmt.Println("}")
 }
 
-func getId(name string) string {
-	id, ok := ids[name]
-	if !ok {
-		id = deriveNodeID(name)
-		ids[name] = id
+// printDOTCondensed is the -collapsecycles DOT variant: every non-trivial
+// cycle collapses to a single node, labeled with its member import paths
+// and filled in a distinct color, with edges rewritten to point at the
+// cycle's representative (its lexicographically smallest member). Like
+// printDOTClusters, this stays a DOT-specific one-off rather than a
+// Renderer, since collapsing an SCC only makes sense for a rendered graph,
+// not for e.g. the JSON output.
+func printDOTCondensed(graph *depgraph.Graph, cycles []depgraph.Cycle, horizontal bool) {
+	repOf := make(map[string]string)
+	label := make(map[string]string)
+	for _, c := range cycles {
+		members := append([]string(nil), c.Packages...)
+		sort.Strings(members)
+		rep := members[0]
+		label[rep] = strings.Join(members, "\\n")
+		for _, p := range members {
+			repOf[p] = rep
+		}
+	}
+	resolve := func(path string) string {
+		if rep, ok := repOf[path]; ok {
+			return rep
+		}
+		return path
 	}
-	return id
-}
 
-func isIgnored(pkg *build.Package) bool {
-	if isInModFile(getId(pkg.ImportPath)) {
-		return true
+	ids := make(map[string]string)
+	id := func(name string) string {
+		got, ok := ids[name]
+		if !ok {
+			got = `"` + name + `"`
+			ids[name] = got
+		}
+		return got
 	}
-	return pkg.Goroot
-	// return pkg.ImportPath || (pkg.Goroot && *ignoreStdlib) || hasPrefixes(pkg.ImportPath, ignoredPrefixes)]
-}
 
-func hasBuildErrors(pkg *build.Package) bool {
-	if len(erroredPkgs) == 0 {
-		return false
+	fmt.Println("digraph godep {")
+	if horizontal {
+		fmt.Println(`rankdir="LR"`)
 	}
+	fmt.Print(`splines=spline
+nodesep=0.4
+ranksep=0.8
+node [shape="box",style="rounded,filled"]
+edge [arrowsize="0.5"]
+`)
 
-	v, ok := erroredPkgs[pkg.ImportPath]
-	if !ok {
-		return false
+	seen := make(map[string]bool)
+	for _, n := range graph.Nodes {
+		rep := resolve(n.ImportPath)
+		if seen[rep] {
+			continue
+		}
+		seen[rep] = true
+		if l, ok := label[rep]; ok {
+			fmt.Printf("%s [label=\"%s\" color=\"orangered\" target=\"_blank\"];\n", id(rep), l)
+			continue
+		}
+		fmt.Printf("%s [label=\"%s\" color=\"%s\" target=\"_blank\"];\n", id(rep), rep, n.Color)
 	}
-	return v
-}
 
-func isInModFile(path string) bool {
-	for _, p := range requiredModules {
-		if strings.Contains(path, p) {
-			return true
+	edgeSeen := make(map[[2]string]bool)
+	for _, e := range graph.Edges {
+		from, to := resolve(e.From), resolve(e.To)
+		if from == to {
+			continue
 		}
+		key := [2]string{from, to}
+		if edgeSeen[key] {
+			continue
+		}
+		edgeSeen[key] = true
+		fmt.Printf("%s -> %s;\n", id(from), id(to))
 	}
-	return false
+	fmt.Println("}")
 }
 
 func die(err error, msg string) {
@@ -223,25 +247,3 @@ func die(err error, msg string) {
 		log.Fatalf("%s: %s", msg, err)
 	}
 }
-
-func mustParseModFile() (module string, required []string) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		die(err, "cannot get current dir")
-	}
-	file := filepath.Join(cwd, "go.mod")
-	data, err := os.ReadFile(file)
-	if err != nil {
-		die(err, "cannot read go.mod")
-	}
-
-	modFile, err := modfile.Parse(file, data, nil)
-	if err != nil {
-		die(err, "failed to parse mod file")
-	}
-	module = modFile.Module.Mod.Path
-	for _, r := range modFile.Require {
-		required = append(required, r.Mod.Path)
-	}
-	return
-}
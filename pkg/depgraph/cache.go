@@ -0,0 +1,312 @@
+package depgraph
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"go/build"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheFormatVersion guards the on-disk encoding below: bump it whenever
+// encodeGraph/decodeGraph's layout changes so old entries are treated as
+// misses instead of being misread.
+const cacheFormatVersion = 1
+
+// computeActionID derives a content hash for a Build call from the build
+// environment (GOOS/GOARCH/CGO_ENABLED, which select which files apply),
+// roots, the GraphOptions fields that affect its result, and a signature of
+// every relevant file under opts.Dir and, in workspace mode, every directory
+// in opts.WorkspaceDirs — not just opts.Dir, since a workspace member
+// outside it can still change what Build returns. Two Builds with the same
+// ActionID are expected to produce the same Graph, so it's safe to serve
+// one from the other's cached result.
+func computeActionID(roots []string, opts GraphOptions) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", cacheFormatVersion)
+
+	// go/build.Default resolves GOOS/GOARCH/CGO_ENABLED the same way the
+	// loader's underlying go list invocation does (env var if set, platform
+	// default otherwise), so two Builds that would evaluate build tags
+	// differently never share a cache entry.
+	fmt.Fprintf(h, "goos:%s\n", build.Default.GOOS)
+	fmt.Fprintf(h, "goarch:%s\n", build.Default.GOARCH)
+	fmt.Fprintf(h, "cgo:%t\n", build.Default.CgoEnabled)
+
+	writeSortedStrings(h, "root", roots)
+	fmt.Fprintf(h, "dir:%s\n", opts.Dir)
+	fmt.Fprintf(h, "tests:%t\n", opts.IncludeTests)
+	fmt.Fprintf(h, "maxlevel:%d\n", opts.MaxLevel)
+	fmt.Fprintf(h, "stoponerror:%t\n", opts.StopOnError)
+	fmt.Fprintf(h, "ignorestdlib:%t\n", opts.IgnoreStdlib)
+	writeSortedStrings(h, "ignoreprefix", opts.IgnorePrefixes)
+	writeSortedStrings(h, "includeprefix", opts.IncludePrefixes)
+	writeSortedStrings(h, "highlight", opts.HighlightModules)
+	writeSortedStrings(h, "workspace", opts.WorkspaceModules)
+	writeSortedStrings(h, "buildtags", opts.BuildTags)
+
+	dirs := append([]string{opts.Dir}, opts.WorkspaceDirs...)
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := hashDirSignature(h, dir); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeSortedStrings(w io.Writer, label string, ss []string) {
+	sorted := append([]string(nil), ss...)
+	sort.Strings(sorted)
+	fmt.Fprintf(w, "%s:%s\n", label, strings.Join(sorted, ","))
+}
+
+// hashDirSignature walks dir and feeds the path, size, and mtime of every
+// .go file and module file (go.mod, go.sum, go.work) into h, skipping .git
+// and vendor directories and everything below them. This is the same
+// approach cmd/go's build cache uses to invalidate without rereading file
+// contents: if nothing's size or mtime moved, nothing needs rereading.
+func hashDirSignature(h io.Writer, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || (d.Name() == "vendor" && path != dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !strings.HasSuffix(name, ".go") && name != "go.mod" && name != "go.sum" && name != "go.work" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+}
+
+// cacheDir is where godepgraph stores cached graphs: alongside other
+// per-user tool caches, respecting XDG_CACHE_HOME via os.UserCacheDir, with
+// os.TempDir as a fallback for environments (e.g. $HOME unset) where that's
+// unavailable.
+func cacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "godepgraph")
+}
+
+func cachePath(actionID string) string {
+	return filepath.Join(cacheDir(), actionID)
+}
+
+// loadCachedGraph reads a previously stored Graph for actionID. A missing or
+// corrupt entry is reported as a cache miss rather than an error: the cache
+// is an optimization, never a source of truth Build depends on.
+func loadCachedGraph(actionID string) (*Graph, bool) {
+	f, err := os.Open(cachePath(actionID))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	graph, err := decodeGraph(bufio.NewReader(f))
+	if err != nil {
+		return nil, false
+	}
+	return graph, true
+}
+
+// storeCachedGraph writes graph to the cache under actionID, via a temp
+// file renamed into place so a concurrent loadCachedGraph never sees a
+// partial write. Errors are ignored: a failed write just costs the next
+// Build a cache miss, not a failed request.
+func storeCachedGraph(actionID string, graph *Graph) {
+	dir := cacheDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(dir, actionID+".tmp*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := encodeGraph(w, graph); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	os.Rename(tmp.Name(), cachePath(actionID))
+}
+
+// encodeGraph writes graph in a small length-prefixed binary layout: a
+// format version, then nodes, then edges. It deliberately avoids
+// encoding/gob so the on-disk format doesn't depend on Go's reflection-based
+// encoding staying stable across versions of this package.
+func encodeGraph(w io.Writer, graph *Graph) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(cacheFormatVersion)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(graph.Nodes))); err != nil {
+		return err
+	}
+	for _, n := range graph.Nodes {
+		if err := writeFields(w, n.ImportPath, n.Color, n.Module); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, n.Goroot); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, n.Cgo); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(n.Errors))); err != nil {
+			return err
+		}
+		for _, e := range n.Errors {
+			if err := writeString(w, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(graph.Edges))); err != nil {
+		return err
+	}
+	for _, e := range graph.Edges {
+		if err := writeFields(w, e.From, e.To); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, e.TestOnly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFields(w io.Writer, ss ...string) error {
+	for _, s := range ss {
+		if err := writeString(w, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// maxCachedStringLen guards readString against a corrupt or truncated cache
+// entry driving an implausible allocation.
+const maxCachedStringLen = 1 << 20
+
+func decodeGraph(r io.Reader) (*Graph, error) {
+	var version uint32
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return nil, err
+	}
+	if version != cacheFormatVersion {
+		return nil, fmt.Errorf("cache: unsupported format version %d", version)
+	}
+
+	var nodeCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &nodeCount); err != nil {
+		return nil, err
+	}
+	graph := &Graph{Nodes: make([]Node, nodeCount)}
+	for i := range graph.Nodes {
+		n := &graph.Nodes[i]
+		var err error
+		if n.ImportPath, err = readString(r); err != nil {
+			return nil, err
+		}
+		if n.Color, err = readString(r); err != nil {
+			return nil, err
+		}
+		if n.Module, err = readString(r); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &n.Goroot); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &n.Cgo); err != nil {
+			return nil, err
+		}
+		var errCount uint32
+		if err := binary.Read(r, binary.LittleEndian, &errCount); err != nil {
+			return nil, err
+		}
+		if errCount > 0 {
+			n.Errors = make([]string, errCount)
+			for j := range n.Errors {
+				if n.Errors[j], err = readString(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	var edgeCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &edgeCount); err != nil {
+		return nil, err
+	}
+	graph.Edges = make([]Edge, edgeCount)
+	for i := range graph.Edges {
+		e := &graph.Edges[i]
+		var err error
+		if e.From, err = readString(r); err != nil {
+			return nil, err
+		}
+		if e.To, err = readString(r); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &e.TestOnly); err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+func readString(r io.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if n > maxCachedStringLen {
+		return "", fmt.Errorf("cache: implausible string length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,137 @@
+package depgraph
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeDecodeGraphRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+	}{
+		{
+			name:  "empty graph",
+			graph: &Graph{},
+		},
+		{
+			name: "single node no edges",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a", Color: "palegreen", Module: "a", Goroot: true}},
+			},
+		},
+		{
+			name: "nodes with errors and cgo",
+			graph: &Graph{
+				Nodes: []Node{
+					{ImportPath: "a", Color: "red", Errors: []string{"a/a.go:1:1: undefined: Foo", "a/a.go:2:1: undefined: Bar"}},
+					{ImportPath: "b", Color: "darkgoldenrod1", Cgo: true},
+				},
+				Edges: []Edge{{From: "a", To: "b", TestOnly: true}},
+			},
+		},
+		{
+			name: "node with empty-string fields",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "", Color: "", Module: ""}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeGraph(&buf, tt.graph); err != nil {
+				t.Fatalf("encodeGraph() error = %v", err)
+			}
+
+			got, err := decodeGraph(&buf)
+			if err != nil {
+				t.Fatalf("decodeGraph() error = %v", err)
+			}
+
+			if len(got.Nodes) != len(tt.graph.Nodes) {
+				t.Fatalf("got %d nodes, want %d", len(got.Nodes), len(tt.graph.Nodes))
+			}
+			for i, n := range tt.graph.Nodes {
+				g := got.Nodes[i]
+				if g.ImportPath != n.ImportPath || g.Color != n.Color || g.Module != n.Module ||
+					g.Goroot != n.Goroot || g.Cgo != n.Cgo || !equalStrings(g.Errors, n.Errors) {
+					t.Errorf("node %d = %+v, want %+v", i, g, n)
+				}
+			}
+
+			if len(got.Edges) != len(tt.graph.Edges) {
+				t.Fatalf("got %d edges, want %d", len(got.Edges), len(tt.graph.Edges))
+			}
+			for i, e := range tt.graph.Edges {
+				g := got.Edges[i]
+				if g.From != e.From || g.To != e.To || g.TestOnly != e.TestOnly {
+					t.Errorf("edge %d = %+v, want %+v", i, g, e)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeGraphRejectsWrongVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeGraph(&buf, &Graph{}); err != nil {
+		t.Fatalf("encodeGraph() error = %v", err)
+	}
+	encoded := buf.Bytes()
+	// The format version is the first little-endian uint32.
+	encoded[0]++
+
+	if _, err := decodeGraph(bytes.NewReader(encoded)); err == nil {
+		t.Error("decodeGraph() with a bumped version succeeded, want error")
+	}
+}
+
+func TestComputeActionIDStableForSameInputs(t *testing.T) {
+	opts := GraphOptions{Dir: t.TempDir(), MaxLevel: 5}
+	roots := []string{"./..."}
+
+	id1, err := computeActionID(roots, opts)
+	if err != nil {
+		t.Fatalf("computeActionID() error = %v", err)
+	}
+	id2, err := computeActionID(roots, opts)
+	if err != nil {
+		t.Fatalf("computeActionID() error = %v", err)
+	}
+	if id1 != id2 {
+		t.Errorf("computeActionID() not stable across calls: %q != %q", id1, id2)
+	}
+}
+
+func TestComputeActionIDDiffersOnOptionChange(t *testing.T) {
+	dir := t.TempDir()
+	base := GraphOptions{Dir: dir, MaxLevel: 5}
+	changed := base
+	changed.MaxLevel = 6
+
+	id1, err := computeActionID([]string{"./..."}, base)
+	if err != nil {
+		t.Fatalf("computeActionID() error = %v", err)
+	}
+	id2, err := computeActionID([]string{"./..."}, changed)
+	if err != nil {
+		t.Fatalf("computeActionID() error = %v", err)
+	}
+	if id1 == id2 {
+		t.Error("computeActionID() identical for different MaxLevel options")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
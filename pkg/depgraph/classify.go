@@ -0,0 +1,172 @@
+package depgraph
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+func (g *Grapher) isIgnored(pkg *packages.Package) bool {
+	if g.opts.IgnoreStdlib && isStdlib(pkg) {
+		return true
+	}
+	if hasPrefix(pkg.PkgPath, g.opts.IgnorePrefixes) {
+		return true
+	}
+	if len(g.opts.IncludePrefixes) > 0 && !hasPrefix(pkg.PkgPath, g.opts.IncludePrefixes) {
+		return true
+	}
+	return false
+}
+
+// workspacePalette assigns a distinct color to each workspace member module,
+// cycling if there are more members than colors.
+var workspacePalette = []string{
+	"lightblue", "lightpink", "lightsalmon", "lightgoldenrod1", "lightcyan", "plum", "wheat",
+}
+
+func (g *Grapher) nodeColor(pkg *packages.Package) string {
+	var color string
+	switch {
+	case hasBuildErrors(pkg):
+		color = "red"
+	case isStdlib(pkg):
+		color = "palegreen"
+	case hasCgo(pkg):
+		color = "darkgoldenrod1"
+	case moduleColor(pkg, g.opts.WorkspaceModules) != "":
+		color = moduleColor(pkg, g.opts.WorkspaceModules)
+	case isHighlighted(pkg, g.opts.HighlightModules):
+		color = "palegoldenrod"
+	default:
+		color = "paleturquoise"
+	}
+	return color
+}
+
+// moduleColor returns the workspacePalette color for pkg's module, or "" if
+// pkg does not belong to one of the given workspace modules.
+func moduleColor(pkg *packages.Package, modules []string) string {
+	if pkg.Module == nil {
+		return ""
+	}
+	for i, m := range modules {
+		if pkg.Module.Path == m {
+			return workspacePalette[i%len(workspacePalette)]
+		}
+	}
+	return ""
+}
+
+// isStdlib reports whether pkg belongs to the standard library. Packages
+// without module information are either in GOROOT or in GOPATH mode, but in
+// practice only the former shows up once module loading is in play — except
+// that packages.Load also hands back a Module-less placeholder, with
+// Errors set instead of a Module, for an import it couldn't resolve at all
+// (typo, missing dependency, network failure). hasBuildErrors must be
+// checked first so that placeholder isn't mistaken for a real GOROOT
+// package.
+func isStdlib(pkg *packages.Package) bool {
+	return pkg.Module == nil && !hasBuildErrors(pkg)
+}
+
+// hasCgo reports whether pkg has cgo preprocessing, which packages.Package
+// surfaces as a divergence between the original and compiled Go file lists
+// rather than as a dedicated CgoFiles field.
+func hasCgo(pkg *packages.Package) bool {
+	return len(pkg.CompiledGoFiles) != len(pkg.GoFiles)
+}
+
+func hasBuildErrors(pkg *packages.Package) bool {
+	return len(pkg.Errors) > 0
+}
+
+// isImportCycleError reports whether every error on pkg is go list's own
+// import-cycle diagnosis. Detecting cycles (see cycles.go) is the point of
+// -failoncycle, so StopOnError must not abort the whole Build on these
+// before FindCycles gets a chance to see them the way it aborts on a
+// genuine build failure.
+func isImportCycleError(pkg *packages.Package) bool {
+	if len(pkg.Errors) == 0 {
+		return false
+	}
+	for _, e := range pkg.Errors {
+		if !strings.Contains(e.Msg, "import cycle not allowed") {
+			return false
+		}
+	}
+	return true
+}
+
+// isTestBinary reports whether pkg is the synthetic "package main" that
+// packages.Config.Tests generates to drive a test (ID and PkgPath both
+// "<import path>.test"), rather than a real, importable package.
+func isTestBinary(pkg *packages.Package) bool {
+	return pkg.ID == pkg.PkgPath && strings.HasSuffix(pkg.ID, ".test")
+}
+
+// choosePrimary picks the variant to represent a package's node: the
+// production form (ID == PkgPath) if one was loaded, otherwise whichever
+// variant (e.g. an external xxx_test package with no production code)
+// sorts first.
+func choosePrimary(variants []*packages.Package) *packages.Package {
+	for _, v := range variants {
+		if v.ID == v.PkgPath {
+			return v
+		}
+	}
+	return variants[0]
+}
+
+// sortedVariants orders a package's variants deterministically: production
+// form first, then by ID.
+func sortedVariants(variants []*packages.Package) []*packages.Package {
+	sort.Slice(variants, func(i, j int) bool {
+		iProd, jProd := variants[i].ID == variants[i].PkgPath, variants[j].ID == variants[j].PkgPath
+		if iProd != jProd {
+			return iProd
+		}
+		return variants[i].ID < variants[j].ID
+	})
+	return variants
+}
+
+// isHighlighted reports whether pkg belongs to one of the given module
+// paths, e.g. the set of modules required across a workspace's go.mod
+// files. This keys on the owning module rather than doing a substring
+// match on the import path, so e.g. github.com/foo/bar no longer
+// false-positives against a required github.com/foo/bar-baz.
+func isHighlighted(pkg *packages.Package, modules []string) bool {
+	if pkg.Module == nil {
+		return false
+	}
+	for _, m := range modules {
+		if pkg.Module.Path == m {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func getImports(pkg *packages.Package) []string {
+	imports := make([]string, 0, len(pkg.Imports))
+	for imp := range pkg.Imports {
+		if imp == pkg.PkgPath {
+			// Don't draw a self-reference when foo_test depends on foo.
+			continue
+		}
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	return imports
+}
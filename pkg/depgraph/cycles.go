@@ -0,0 +1,220 @@
+package depgraph
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Cycle is one non-trivial strongly connected component of a Graph: a set
+// of packages that import each other, directly or transitively. Go's
+// compiler forbids these between real packages, but an Overlay edit (see
+// GraphOptions.Overlay) can describe an in-memory change that introduces
+// one before it's ever compiled.
+type Cycle struct {
+	// Packages lists every import path in the cycle, ordered as a path
+	// around it; the start is not repeated at the end. A cycle found in
+	// Graph.Edges starts at its lexicographically smallest member; a cycle
+	// recovered from a `go list` error keeps the order of its import stack.
+	Packages []string
+}
+
+// String renders the cycle as the path that demonstrates it, e.g.
+// "a -> b -> c -> a".
+func (c Cycle) String() string {
+	if len(c.Packages) == 0 {
+		return ""
+	}
+	path := append(append([]string(nil), c.Packages...), c.Packages[0])
+	return strings.Join(path, " -> ")
+}
+
+// importCycleStackRE matches the import stack golang.org/x/tools/go/packages
+// embeds (verbatim from `go list`) in the one Error it attaches to a cycle's
+// entry package, e.g. "import cycle not allowed: import stack: [a b a]".
+var importCycleStackRE = regexp.MustCompile(`import stack: \[([^\]]*)\]`)
+
+// FindCycles returns every import cycle reachable from graph: both cycles
+// `go list` itself detected (see cyclesFromErrors) and any that still show
+// up structurally in graph.Edges (via Tarjan's strongly connected
+// components), deduplicated by member set.
+func FindCycles(graph *Graph) []Cycle {
+	cycles := cyclesFromErrors(graph)
+	seen := make(map[string]bool, len(cycles))
+	for _, c := range cycles {
+		seen[cycleKey(c.Packages)] = true
+	}
+
+	adj := make(map[string][]string, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		adj[n.ImportPath] = nil
+	}
+	for _, e := range graph.Edges {
+		adj[e.From] = append(adj[e.From], e.To)
+	}
+
+	order := make([]string, 0, len(graph.Nodes))
+	for _, n := range graph.Nodes {
+		order = append(order, n.ImportPath)
+	}
+	sort.Strings(order)
+
+	t := &tarjan{
+		adj:     adj,
+		index:   make(map[string]int),
+		lowlink: make(map[string]int),
+		onStack: make(map[string]bool),
+	}
+	for _, v := range order {
+		if _, visited := t.index[v]; !visited {
+			t.strongconnect(v)
+		}
+	}
+
+	for _, scc := range t.sccs {
+		if len(scc) > 1 || hasSelfEdge(adj, scc[0]) {
+			c := Cycle{Packages: orderCycle(scc, adj)}
+			if key := cycleKey(c.Packages); !seen[key] {
+				seen[key] = true
+				cycles = append(cycles, c)
+			}
+		}
+	}
+	return cycles
+}
+
+// cyclesFromErrors recovers the cycles `go list` detected and pruned before
+// Build ever saw them: when a package participates in an import cycle, `go
+// list` refuses to populate its Imports with the edge that closes the loop,
+// so that edge never reaches graph.Edges — but it does embed the full
+// import stack in the Error it attaches to the cycle's entry package, which
+// render copies verbatim into Node.Errors. Parsing that message is the only
+// way to recover these cycles; walking graph.Edges (see the Tarjan pass
+// below) cannot see them.
+func cyclesFromErrors(graph *Graph) []Cycle {
+	var cycles []Cycle
+	for _, n := range graph.Nodes {
+		for _, e := range n.Errors {
+			m := importCycleStackRE.FindStringSubmatch(e)
+			if m == nil {
+				continue
+			}
+			stack := strings.Fields(m[1])
+			if len(stack) > 1 && stack[0] == stack[len(stack)-1] {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				continue
+			}
+			cycles = append(cycles, Cycle{Packages: stack})
+		}
+	}
+	return cycles
+}
+
+// cycleKey identifies a cycle by its member set, independent of the order
+// its path happens to be reported in, so the same cycle recovered by both
+// cyclesFromErrors and the Tarjan pass in FindCycles is only reported once.
+func cycleKey(packages []string) string {
+	sorted := append([]string(nil), packages...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func hasSelfEdge(adj map[string][]string, v string) bool {
+	for _, w := range adj[v] {
+		if w == v {
+			return true
+		}
+	}
+	return false
+}
+
+// orderCycle walks the edges internal to scc by depth-first search,
+// starting from its lexicographically smallest member, until it finds a
+// path back to the start. Any such path is a valid witness for the cycle;
+// this doesn't try to find the shortest one.
+func orderCycle(scc []string, adj map[string][]string) []string {
+	sorted := append([]string(nil), scc...)
+	sort.Strings(sorted)
+	if len(sorted) == 1 {
+		return sorted
+	}
+
+	members := make(map[string]bool, len(sorted))
+	for _, v := range sorted {
+		members[v] = true
+	}
+	start := sorted[0]
+	visited := make(map[string]bool)
+	var path []string
+
+	var dfs func(v string) bool
+	dfs = func(v string) bool {
+		visited[v] = true
+		path = append(path, v)
+		for _, w := range adj[v] {
+			if !members[w] {
+				continue
+			}
+			if w == start && len(path) > 1 {
+				return true
+			}
+			if !visited[w] && dfs(w) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	dfs(start)
+	return path
+}
+
+// tarjan holds the bookkeeping state for one run of Tarjan's strongly
+// connected components algorithm over a string-keyed adjacency list.
+type tarjan struct {
+	adj     map[string][]string
+	index   map[string]int
+	lowlink map[string]int
+	onStack map[string]bool
+	stack   []string
+	next    int
+	sccs    [][]string
+}
+
+func (t *tarjan) strongconnect(v string) {
+	t.index[v] = t.next
+	t.lowlink[v] = t.next
+	t.next++
+	t.stack = append(t.stack, v)
+	t.onStack[v] = true
+
+	for _, w := range t.adj[v] {
+		if _, visited := t.index[w]; !visited {
+			t.strongconnect(w)
+			if t.lowlink[w] < t.lowlink[v] {
+				t.lowlink[v] = t.lowlink[w]
+			}
+		} else if t.onStack[w] {
+			if t.index[w] < t.lowlink[v] {
+				t.lowlink[v] = t.index[w]
+			}
+		}
+	}
+
+	if t.lowlink[v] == t.index[v] {
+		var scc []string
+		for {
+			n := len(t.stack) - 1
+			w := t.stack[n]
+			t.stack = t.stack[:n]
+			t.onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		t.sccs = append(t.sccs, scc)
+	}
+}
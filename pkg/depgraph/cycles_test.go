@@ -0,0 +1,187 @@
+package depgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  []string // cycleKey of each expected cycle
+	}{
+		{
+			name:  "no nodes",
+			graph: &Graph{},
+			want:  nil,
+		},
+		{
+			name: "no edges",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}, {ImportPath: "b"}},
+			},
+			want: nil,
+		},
+		{
+			name: "acyclic chain",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}, {ImportPath: "b"}, {ImportPath: "c"}},
+				Edges: []Edge{{From: "a", To: "b"}, {From: "b", To: "c"}},
+			},
+			want: nil,
+		},
+		{
+			name: "direct two-cycle",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}, {ImportPath: "b"}},
+				Edges: []Edge{{From: "a", To: "b"}, {From: "b", To: "a"}},
+			},
+			want: []string{cycleKey([]string{"a", "b"})},
+		},
+		{
+			name: "transitive three-cycle",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}, {ImportPath: "b"}, {ImportPath: "c"}},
+				Edges: []Edge{{From: "a", To: "b"}, {From: "b", To: "c"}, {From: "c", To: "a"}},
+			},
+			want: []string{cycleKey([]string{"a", "b", "c"})},
+		},
+		{
+			name: "self edge",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}},
+				Edges: []Edge{{From: "a", To: "a"}},
+			},
+			want: []string{cycleKey([]string{"a"})},
+		},
+		{
+			name: "two independent cycles",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}, {ImportPath: "b"}, {ImportPath: "x"}, {ImportPath: "y"}},
+				Edges: []Edge{
+					{From: "a", To: "b"}, {From: "b", To: "a"},
+					{From: "x", To: "y"}, {From: "y", To: "x"},
+				},
+			},
+			want: []string{cycleKey([]string{"a", "b"}), cycleKey([]string{"x", "y"})},
+		},
+		{
+			name: "error-recovered cycle pruned from edges",
+			graph: &Graph{
+				Nodes: []Node{
+					{ImportPath: "a", Errors: []string{"a/a.go:1:1: import cycle not allowed: import stack: [a b a]"}},
+					{ImportPath: "b"},
+				},
+				// go list would have pruned the b -> a edge that closes the loop.
+				Edges: []Edge{{From: "a", To: "b"}},
+			},
+			want: []string{cycleKey([]string{"a", "b"})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindCycles(tt.graph)
+			var gotKeys []string
+			for _, c := range got {
+				gotKeys = append(gotKeys, cycleKey(c.Packages))
+			}
+			if !sameSet(gotKeys, tt.want) {
+				t.Errorf("FindCycles() = %v, want keys %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCyclesFromErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  [][]string
+	}{
+		{
+			name: "no errors",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a"}},
+			},
+			want: nil,
+		},
+		{
+			name: "non-cycle error ignored",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a", Errors: []string{"a/a.go:1:1: undefined: Foo"}}},
+			},
+			want: nil,
+		},
+		{
+			name: "cycle stack with closing repeat trimmed",
+			graph: &Graph{
+				Nodes: []Node{{ImportPath: "a", Errors: []string{"import cycle not allowed: import stack: [a b c a]"}}},
+			},
+			want: [][]string{{"a", "b", "c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cyclesFromErrors(tt.graph)
+			var gotPackages [][]string
+			for _, c := range got {
+				gotPackages = append(gotPackages, c.Packages)
+			}
+			if len(gotPackages) != len(tt.want) || (len(gotPackages) > 0 && !reflect.DeepEqual(gotPackages, tt.want)) {
+				t.Errorf("cyclesFromErrors() = %v, want %v", gotPackages, tt.want)
+			}
+		})
+	}
+}
+
+func TestCycleString(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Cycle
+		want string
+	}{
+		{name: "empty", c: Cycle{}, want: ""},
+		{name: "single", c: Cycle{Packages: []string{"a"}}, want: "a -> a"},
+		{name: "multiple", c: Cycle{Packages: []string{"a", "b", "c"}}, want: "a -> b -> c -> a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCycleKeyOrderIndependent(t *testing.T) {
+	a := cycleKey([]string{"a", "b", "c"})
+	b := cycleKey([]string{"c", "a", "b"})
+	if a != b {
+		t.Errorf("cycleKey order-dependent: %q != %q", a, b)
+	}
+}
+
+// sameSet reports whether got and want contain the same elements with the
+// same multiplicities, ignoring order.
+func sameSet(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	counts := make(map[string]int, len(want))
+	for _, w := range want {
+		counts[w]++
+	}
+	for _, g := range got {
+		counts[g]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
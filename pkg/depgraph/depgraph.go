@@ -0,0 +1,290 @@
+// Package depgraph builds an import graph for a set of Go package patterns.
+//
+// It is the library half of godepgraph: cmd/godepgraph (the main package at
+// the repository root) is a thin CLI that turns flags into a GraphOptions,
+// calls Build, and renders the result. Callers that want to embed godepgraph
+// in another tool, or run several independent graphs in one process, should
+// use this package directly instead of shelling out.
+package depgraph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// GraphOptions controls how a Grapher loads packages and decides which
+// nodes and edges belong in the resulting Graph.
+type GraphOptions struct {
+	// Dir is the directory patterns are resolved relative to. Empty means
+	// the current working directory.
+	Dir string
+
+	// IncludeTests includes test-only imports (TestImports/XTestImports,
+	// surfaced by the loader as separate test-variant packages).
+	IncludeTests bool
+
+	// MaxLevel caps how many import hops from a root package are walked.
+	// The shallowest depth at which a package is reached wins.
+	MaxLevel int
+
+	// StopOnError aborts the build on the first package load error instead
+	// of rendering it as an error-colored node. A package whose only errors
+	// are import-cycle errors does not abort the build.
+	StopOnError bool
+
+	// IgnoreStdlib excludes standard library packages from the graph.
+	IgnoreStdlib bool
+
+	// IgnorePrefixes excludes any package whose import path has one of
+	// these prefixes.
+	IgnorePrefixes []string
+
+	// IncludePrefixes, if non-empty, restricts the graph to packages whose
+	// import path has one of these prefixes.
+	IncludePrefixes []string
+
+	// HighlightModules lists module paths to render in a distinct color,
+	// e.g. the modules required across every go.mod in a workspace.
+	HighlightModules []string
+
+	// WorkspaceModules lists the module paths that are local workspace
+	// members (the "use" directives of a go.work file, or the root
+	// module itself outside a workspace). Each gets its own color in
+	// nodeColor, distinct from HighlightModules.
+	WorkspaceModules []string
+
+	// WorkspaceDirs lists the on-disk directory of each module in
+	// WorkspaceModules, in the same order. The graph cache (see
+	// computeActionID in cache.go) fingerprints every one of these trees
+	// instead of just Dir, so editing any workspace member invalidates a
+	// cached graph, not only edits inside the directory the tool was
+	// invoked from.
+	WorkspaceDirs []string
+
+	// Horizontal is a rendering hint (DOT rankdir=LR); Build does not use
+	// it directly but carries it through for renderers that consume
+	// GraphOptions alongside a Graph.
+	Horizontal bool
+
+	// BuildTags are passed through to the underlying build system as
+	// -tags.
+	BuildTags []string
+
+	// Overlay maps absolute file paths to file contents, letting callers
+	// graph in-memory edits without writing them to disk. A non-empty
+	// Overlay disables the on-disk graph cache, since it represents
+	// in-memory edits the cache key can't see.
+	Overlay map[string][]byte
+
+	// DisableCache skips the on-disk graph cache entirely, forcing a full
+	// reload. Useful for benchmarking or when the cache is suspected stale.
+	DisableCache bool
+}
+
+// Node is one package in a Graph. Module/Goroot/Cgo/Errors are copied out
+// of Pkg at build time so a Graph loaded from the on-disk cache (see
+// cache.go) is just as usable as a freshly built one, without needing to
+// reconstruct a *packages.Package. Pkg itself is nil on a cache hit.
+type Node struct {
+	ImportPath string
+	Color      string
+	Module     string
+	Goroot     bool
+	Cgo        bool
+	Errors     []string
+	Pkg        *packages.Package
+}
+
+// Edge is a directed import from one package to another; both ends are
+// already-included Nodes. TestOnly is set when the import only exists
+// because of a test file (TestImports/XTestImports) and the production
+// package does not import it directly.
+type Edge struct {
+	From     string
+	To       string
+	TestOnly bool
+}
+
+// Graph is the result of a Build: every included package and the import
+// edges between them.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Grapher builds Graphs for a fixed set of GraphOptions. It holds no
+// mutable state between calls, so it's safe to reuse and to run
+// concurrently from multiple goroutines.
+type Grapher struct {
+	opts GraphOptions
+}
+
+// New returns a Grapher configured with opts.
+func New(opts GraphOptions) *Grapher {
+	return &Grapher{opts: opts}
+}
+
+// Build resolves roots (package patterns, e.g. an import path, "./...", or
+// "std") and returns the import graph reachable from them.
+func (g *Grapher) Build(ctx context.Context, roots []string) (*Graph, error) {
+	cacheable := !g.opts.DisableCache && len(g.opts.Overlay) == 0
+	var actionID string
+	if cacheable {
+		var err error
+		actionID, err = computeActionID(roots, g.opts)
+		if err == nil {
+			if graph, ok := loadCachedGraph(actionID); ok {
+				return graph, nil
+			}
+		}
+	}
+
+	ld := newLoader(ctx, g.opts)
+	loaded, rootIDs, err := ld.load(roots...)
+	if err != nil {
+		return nil, err
+	}
+
+	included, err := g.walk(loaded, rootIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := g.render(included)
+	if cacheable && actionID != "" {
+		storeCachedGraph(actionID, graph)
+	}
+	return graph, nil
+}
+
+// walk breadth-first searches the loaded package set from rootIDs, keyed by
+// packages.Package.ID rather than import path so that a package's
+// test-variant (same PkgPath, distinct ID, see packages.Config.Tests) isn't
+// merged into its production form before render groups them back together.
+// It honors MaxLevel (the shallowest depth at which a package is reached
+// wins) and StopOnError, and returns the packages that belong in the graph.
+func (g *Grapher) walk(loaded map[string]*packages.Package, rootIDs []string) (map[string]*packages.Package, error) {
+	included := make(map[string]*packages.Package)
+	depth := make(map[string]int)
+	queue := make([]string, 0, len(rootIDs))
+	for _, r := range rootIDs {
+		if _, ok := depth[r]; ok {
+			continue
+		}
+		depth[r] = 0
+		queue = append(queue, r)
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		pkg := loaded[id]
+		if pkg == nil {
+			continue
+		}
+		level := depth[id]
+		if level > g.opts.MaxLevel {
+			continue
+		}
+
+		if g.opts.StopOnError && hasBuildErrors(pkg) && !isImportCycleError(pkg) {
+			return nil, fmt.Errorf("failed to load %s (at level %d):\n%s", id, level, pkg.Errors[0])
+		}
+
+		if _, seen := included[id]; seen {
+			continue
+		}
+		included[id] = pkg
+
+		for _, imp := range pkg.Imports {
+			if d, ok := depth[imp.ID]; !ok || level+1 < d {
+				depth[imp.ID] = level + 1
+			}
+			if _, seen := included[imp.ID]; !seen {
+				queue = append(queue, imp.ID)
+			}
+		}
+	}
+	return included, nil
+}
+
+// render groups the walked packages by import path (collapsing each
+// package's production and test-variant forms back into one node), applies
+// the ignore/prefix filters in opts, and sorts nodes and edges for
+// deterministic output. An edge is TestOnly if every variant that
+// contributed it was a test variant.
+func (g *Grapher) render(included map[string]*packages.Package) *Graph {
+	byPath := make(map[string][]*packages.Package)
+	for _, pkg := range included {
+		if isTestBinary(pkg) {
+			continue
+		}
+		byPath[pkg.PkgPath] = append(byPath[pkg.PkgPath], pkg)
+	}
+
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	graph := &Graph{}
+	for _, path := range paths {
+		variants := sortedVariants(byPath[path])
+		primary := choosePrimary(variants)
+		if g.isIgnored(primary) {
+			continue
+		}
+		node := Node{
+			ImportPath: path,
+			Color:      g.nodeColor(primary),
+			Goroot:     isStdlib(primary),
+			Cgo:        hasCgo(primary),
+			Pkg:        primary,
+		}
+		if primary.Module != nil {
+			node.Module = primary.Module.Path
+		}
+		for _, e := range primary.Errors {
+			node.Errors = append(node.Errors, e.Error())
+		}
+		graph.Nodes = append(graph.Nodes, node)
+
+		type edgeState struct {
+			testOnly bool
+		}
+		edges := make(map[string]*edgeState)
+		var order []string
+		for _, v := range variants {
+			isProd := v.ID == v.PkgPath
+			for _, imp := range getImports(v) {
+				impVariants, ok := byPath[imp]
+				if !ok {
+					continue
+				}
+				if g.isIgnored(choosePrimary(sortedVariants(impVariants))) {
+					continue
+				}
+				if st, ok := edges[imp]; ok {
+					st.testOnly = st.testOnly && !isProd
+					continue
+				}
+				edges[imp] = &edgeState{testOnly: !isProd}
+				order = append(order, imp)
+			}
+		}
+		for _, imp := range order {
+			graph.Edges = append(graph.Edges, Edge{From: path, To: imp, TestOnly: edges[imp].testOnly})
+		}
+	}
+	return graph
+}
+
+// Build is a convenience wrapper around New(opts).Build(ctx, roots).
+func Build(ctx context.Context, roots []string, opts GraphOptions) (*Graph, error) {
+	return New(opts).Build(ctx, roots)
+}
@@ -0,0 +1,122 @@
+package depgraph
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loaderMode is the set of package fields godepgraph needs out of
+// golang.org/x/tools/go/packages: just enough to walk the import graph and
+// classify nodes without asking the loader to type-check anything.
+const loaderMode = packages.NeedName |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedModule |
+	packages.NeedFiles |
+	packages.NeedCompiledGoFiles
+
+// loaderWorkers caps how many patterns load concurrently. Each pattern's
+// packages.Load call shells out to `go list`, which is IO- and
+// process-spawn-bound rather than CPU-bound, so overlapping a handful of
+// them shortens wall-clock time on a multi-pattern or multi-module Build
+// without oversubscribing the machine the way one goroutine per pattern
+// would.
+const loaderWorkers = 4
+
+// loader wraps a packages.Config and resolves root patterns into the full
+// transitive set of packages.Package reachable from them.
+type loader struct {
+	cfg *packages.Config
+}
+
+func newLoader(ctx context.Context, opts GraphOptions) *loader {
+	cfg := &packages.Config{
+		Mode:    loaderMode,
+		Context: ctx,
+		Dir:     opts.Dir,
+		Tests:   opts.IncludeTests,
+		Overlay: opts.Overlay,
+	}
+	if len(opts.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(opts.BuildTags, ",")}
+	}
+	return &loader{cfg: cfg}
+}
+
+// load resolves each of patterns (which may themselves expand to more than
+// one package, e.g. "./..." or "std") independently, across a small pool of
+// worker goroutines, then flattens every root's transitive import set into
+// a single map keyed by package ID. ID rather than PkgPath is the right key
+// here: with Tests enabled, a package's production and test-variant forms
+// share a PkgPath but have distinct IDs, and callers need to see both
+// before deciding how to merge them. It also returns the IDs of the root
+// packages, grouped by pattern in the order patterns was given, so callers
+// can seed a graph walk without re-matching patterns.
+func (l *loader) load(patterns ...string) (map[string]*packages.Package, []string, error) {
+	jobs := make(chan string, len(patterns))
+	for _, p := range patterns {
+		jobs <- p
+	}
+	close(jobs)
+
+	type patternResult struct {
+		roots []*packages.Package
+		err   error
+	}
+	resultsByPattern := make(map[string]patternResult, len(patterns))
+	var resultsMu sync.Mutex
+
+	var pkgs sync.Map // package ID -> *packages.Package, shared across workers
+	var walk func(pkg *packages.Package)
+	walk = func(pkg *packages.Package) {
+		if _, loaded := pkgs.LoadOrStore(pkg.ID, pkg); loaded {
+			return
+		}
+		for _, imp := range pkg.Imports {
+			walk(imp)
+		}
+	}
+
+	workers := loaderWorkers
+	if workers > len(patterns) {
+		workers = len(patterns)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pattern := range jobs {
+				roots, err := packages.Load(l.cfg, pattern)
+				resultsMu.Lock()
+				resultsByPattern[pattern] = patternResult{roots: roots, err: err}
+				resultsMu.Unlock()
+				for _, pkg := range roots {
+					walk(pkg)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rootIDs []string
+	for _, pattern := range patterns {
+		r := resultsByPattern[pattern]
+		if r.err != nil {
+			return nil, nil, r.err
+		}
+		for _, pkg := range r.roots {
+			rootIDs = append(rootIDs, pkg.ID)
+		}
+	}
+
+	out := make(map[string]*packages.Package)
+	pkgs.Range(func(k, v any) bool {
+		out[k.(string)] = v.(*packages.Package)
+		return true
+	})
+	return out, rootIDs, nil
+}
@@ -0,0 +1,128 @@
+package depgraph
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestModule lays out a small module on disk for the loader to load
+// for real: load shells out to `go list` via packages.Load, so there's no
+// seam to fake it out at.
+//
+//	root (module root)
+//	├── a -> imports root/b
+//	└── b
+func writeTestModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	mustWriteFile(t, filepath.Join(dir, "go.mod"), "module loadertest\n\ngo 1.21\n")
+	mustWriteFile(t, filepath.Join(dir, "a", "a.go"), "package a\n\nimport _ \"loadertest/b\"\n")
+	mustWriteFile(t, filepath.Join(dir, "b", "b.go"), "package b\n")
+	return dir
+}
+
+func mustWriteFile(t *testing.T, path, contents string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoaderLoadResolvesTransitiveImports(t *testing.T) {
+	dir := writeTestModule(t)
+	l := newLoader(context.Background(), GraphOptions{Dir: dir})
+
+	loaded, rootIDs, err := l.load("./a")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(rootIDs) != 1 {
+		t.Fatalf("got %d root IDs, want 1", len(rootIDs))
+	}
+
+	root, ok := loaded[rootIDs[0]]
+	if !ok {
+		t.Fatalf("loaded map missing root %q", rootIDs[0])
+	}
+	if root.PkgPath != "loadertest/a" {
+		t.Errorf("root.PkgPath = %q, want loadertest/a", root.PkgPath)
+	}
+
+	var foundB bool
+	for _, pkg := range loaded {
+		if pkg.PkgPath == "loadertest/b" {
+			foundB = true
+		}
+	}
+	if !foundB {
+		t.Error("load() did not resolve the transitive import loadertest/b")
+	}
+}
+
+func TestLoaderLoadPreservesRootOrderAcrossPatterns(t *testing.T) {
+	dir := writeTestModule(t)
+	l := newLoader(context.Background(), GraphOptions{Dir: dir})
+
+	// As many patterns as loaderWorkers so the worker pool fans the
+	// patterns out across goroutines rather than running them one at a time.
+	patterns := []string{"./b", "./a", "./b", "./a"}
+	_, rootIDs, err := l.load(patterns...)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(rootIDs) != len(patterns) {
+		t.Fatalf("got %d root IDs, want %d", len(rootIDs), len(patterns))
+	}
+
+	want := []string{"loadertest/b", "loadertest/a", "loadertest/b", "loadertest/a"}
+	for i, id := range rootIDs {
+		if id != want[i] {
+			t.Errorf("rootIDs[%d] = %q, want %q", i, id, want[i])
+		}
+	}
+}
+
+func TestLoaderLoadDedupesSharedPackage(t *testing.T) {
+	dir := writeTestModule(t)
+	l := newLoader(context.Background(), GraphOptions{Dir: dir})
+
+	loaded, _, err := l.load("./a", "./b")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	var bCount int
+	for _, pkg := range loaded {
+		if pkg.PkgPath == "loadertest/b" {
+			bCount++
+		}
+	}
+	if bCount != 1 {
+		t.Errorf("loadertest/b appears %d times in the loaded set, want 1", bCount)
+	}
+}
+
+func TestLoaderLoadReportsUnresolvedPatternAsErrorPackage(t *testing.T) {
+	dir := writeTestModule(t)
+	l := newLoader(context.Background(), GraphOptions{Dir: dir})
+
+	loaded, rootIDs, err := l.load("./nonexistent")
+	if err != nil {
+		t.Fatalf("load() error = %v, want nil (packages.Load reports this as a package-level error, not a load error)", err)
+	}
+	if len(rootIDs) != 1 {
+		t.Fatalf("got %d root IDs, want 1", len(rootIDs))
+	}
+	root, ok := loaded[rootIDs[0]]
+	if !ok {
+		t.Fatalf("loaded map missing root %q", rootIDs[0])
+	}
+	if len(root.Errors) == 0 {
+		t.Error("root package for an unresolved pattern has no Errors set")
+	}
+}
@@ -0,0 +1,35 @@
+package render
+
+import (
+	"fmt"
+	"io"
+)
+
+// d2Renderer writes D2 (d2lang.com), a text diagram format rendered by the
+// d2 CLI or play.d2lang.com.
+type d2Renderer struct {
+	w     io.Writer
+	alias *idAllocator
+}
+
+func newD2Renderer(w io.Writer, opts Options) *d2Renderer {
+	return &d2Renderer{w: w, alias: newIDAllocator("n")}
+}
+
+func (r *d2Renderer) BeginGraph() error { return nil }
+
+func (r *d2Renderer) WriteNode(id, label string, attrs map[string]string) error {
+	_, err := fmt.Fprintf(r.w, "%s: %q {\n  style.fill: %q\n}\n", r.alias.get(id), label, attrs["color"])
+	return err
+}
+
+func (r *d2Renderer) WriteEdge(from, to string, attrs map[string]string) error {
+	if attrs["test_only"] == "true" {
+		_, err := fmt.Fprintf(r.w, "%s -> %s {\n  style.stroke-dash: 3\n}\n", r.alias.get(from), r.alias.get(to))
+		return err
+	}
+	_, err := fmt.Fprintf(r.w, "%s -> %s\n", r.alias.get(from), r.alias.get(to))
+	return err
+}
+
+func (r *d2Renderer) EndGraph() error { return nil }
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"fmt"
+	"io"
+)
+
+// dotRenderer writes Graphviz DOT, godepgraph's original and default
+// output format.
+type dotRenderer struct {
+	w    io.Writer
+	opts Options
+}
+
+func newDOTRenderer(w io.Writer, opts Options) *dotRenderer {
+	return &dotRenderer{w: w, opts: opts}
+}
+
+func (r *dotRenderer) BeginGraph() error {
+	fmt.Fprintln(r.w, "digraph godep {")
+	if r.opts.Horizontal {
+		fmt.Fprintln(r.w, `rankdir="LR"`)
+	}
+	fmt.Fprint(r.w, `splines=spline
+nodesep=0.4
+ranksep=0.8
+node [shape="box",style="rounded,filled"]
+edge [arrowsize="0.5"]
+`)
+	return nil
+}
+
+func (r *dotRenderer) WriteNode(id, label string, attrs map[string]string) error {
+	_, err := fmt.Fprintf(r.w, "%s [label=%q color=%q target=\"_blank\"];\n", dotID(id), label, attrs["color"])
+	return err
+}
+
+func (r *dotRenderer) WriteEdge(from, to string, attrs map[string]string) error {
+	if attrs["test_only"] == "true" {
+		_, err := fmt.Fprintf(r.w, "%s -> %s [style=\"dashed\"];\n", dotID(from), dotID(to))
+		return err
+	}
+	_, err := fmt.Fprintf(r.w, "%s -> %s;\n", dotID(from), dotID(to))
+	return err
+}
+
+func (r *dotRenderer) EndGraph() error {
+	_, err := fmt.Fprintln(r.w, "}")
+	return err
+}
+
+func dotID(name string) string {
+	return fmt.Sprintf("%q", name)
+}
@@ -0,0 +1,56 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// graphMLRenderer writes GraphML, an XML interchange format readable by
+// tools like yEd and Gephi.
+type graphMLRenderer struct {
+	w     io.Writer
+	alias *idAllocator
+}
+
+func newGraphMLRenderer(w io.Writer, opts Options) *graphMLRenderer {
+	return &graphMLRenderer{w: w, alias: newIDAllocator("n")}
+}
+
+func (r *graphMLRenderer) BeginGraph() error {
+	_, err := fmt.Fprint(r.w, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+<key id="label" for="node" attr.name="label" attr.type="string"/>
+<key id="color" for="node" attr.name="color" attr.type="string"/>
+<key id="test_only" for="edge" attr.name="test_only" attr.type="boolean"/>
+<graph id="godep" edgedefault="directed">
+`)
+	return err
+}
+
+func (r *graphMLRenderer) WriteNode(id, label string, attrs map[string]string) error {
+	var buf []byte
+	buf = append(buf, fmt.Sprintf("<node id=%q>\n", r.alias.get(id))...)
+	buf = append(buf, fmt.Sprintf("<data key=\"label\">%s</data>\n", xmlEscape(label))...)
+	buf = append(buf, fmt.Sprintf("<data key=\"color\">%s</data>\n", xmlEscape(attrs["color"]))...)
+	buf = append(buf, "</node>\n"...)
+	_, err := r.w.Write(buf)
+	return err
+}
+
+func (r *graphMLRenderer) WriteEdge(from, to string, attrs map[string]string) error {
+	_, err := fmt.Fprintf(r.w, "<edge source=%q target=%q>\n<data key=\"test_only\">%s</data>\n</edge>\n",
+		r.alias.get(from), r.alias.get(to), attrs["test_only"])
+	return err
+}
+
+func (r *graphMLRenderer) EndGraph() error {
+	_, err := fmt.Fprint(r.w, "</graph>\n</graphml>\n")
+	return err
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,26 @@
+package render
+
+import "strconv"
+
+// idAllocator assigns a stable, format-safe alias (prefix + sequence
+// number) to each import path the first time it's seen. Mermaid, D2, and
+// GraphML node IDs can't contain the dots and slashes that show up in Go
+// import paths, so they reference nodes by alias instead and keep the
+// import path only as the human-readable label.
+type idAllocator struct {
+	prefix  string
+	aliases map[string]string
+}
+
+func newIDAllocator(prefix string) *idAllocator {
+	return &idAllocator{prefix: prefix, aliases: make(map[string]string)}
+}
+
+func (a *idAllocator) get(name string) string {
+	if alias, ok := a.aliases[name]; ok {
+		return alias
+	}
+	alias := a.prefix + strconv.Itoa(len(a.aliases))
+	a.aliases[name] = alias
+	return alias
+}
@@ -0,0 +1,73 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonGraph is the stable schema jsonRenderer emits, so downstream tools
+// (jq, custom visualizers) can consume a godepgraph run without scraping
+// DOT.
+type jsonGraph struct {
+	Module string     `json:"module"`
+	Nodes  []jsonNode `json:"nodes"`
+	Edges  []jsonEdge `json:"edges"`
+}
+
+type jsonNode struct {
+	ID         string   `json:"id"`
+	ImportPath string   `json:"import_path"`
+	Module     string   `json:"module"`
+	Goroot     bool     `json:"goroot"`
+	Cgo        bool     `json:"cgo"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+type jsonEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	TestOnly bool   `json:"test_only"`
+}
+
+// jsonRenderer accumulates nodes and edges and marshals them as a single
+// JSON document on EndGraph, since the schema is a whole-graph object
+// rather than a stream of records.
+type jsonRenderer struct {
+	w io.Writer
+	g jsonGraph
+}
+
+func newJSONRenderer(w io.Writer, opts Options) *jsonRenderer {
+	return &jsonRenderer{w: w, g: jsonGraph{Module: opts.Module}}
+}
+
+func (r *jsonRenderer) BeginGraph() error { return nil }
+
+func (r *jsonRenderer) WriteNode(id, label string, attrs map[string]string) error {
+	n := jsonNode{
+		ID:         id,
+		ImportPath: label,
+		Module:     attrs["module"],
+		Goroot:     attrs["goroot"] == "true",
+		Cgo:        attrs["cgo"] == "true",
+	}
+	if errs := attrs["errors"]; errs != "" {
+		n.Errors = strings.Split(errs, "; ")
+	}
+	r.g.Nodes = append(r.g.Nodes, n)
+	return nil
+}
+
+func (r *jsonRenderer) WriteEdge(from, to string, attrs map[string]string) error {
+	testOnly, _ := strconv.ParseBool(attrs["test_only"])
+	r.g.Edges = append(r.g.Edges, jsonEdge{From: from, To: to, TestOnly: testOnly})
+	return nil
+}
+
+func (r *jsonRenderer) EndGraph() error {
+	enc := json.NewEncoder(r.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.g)
+}
@@ -0,0 +1,43 @@
+package render
+
+import (
+	"fmt"
+	"io"
+)
+
+// mermaidRenderer writes a Mermaid flowchart, suitable for embedding
+// directly in a Markdown/GitHub README.
+type mermaidRenderer struct {
+	w     io.Writer
+	opts  Options
+	alias *idAllocator
+}
+
+func newMermaidRenderer(w io.Writer, opts Options) *mermaidRenderer {
+	return &mermaidRenderer{w: w, opts: opts, alias: newIDAllocator("n")}
+}
+
+func (r *mermaidRenderer) BeginGraph() error {
+	dir := "TD"
+	if r.opts.Horizontal {
+		dir = "LR"
+	}
+	_, err := fmt.Fprintf(r.w, "graph %s\n", dir)
+	return err
+}
+
+func (r *mermaidRenderer) WriteNode(id, label string, attrs map[string]string) error {
+	_, err := fmt.Fprintf(r.w, "  %s[%q]\n", r.alias.get(id), label)
+	return err
+}
+
+func (r *mermaidRenderer) WriteEdge(from, to string, attrs map[string]string) error {
+	arrow := "-->"
+	if attrs["test_only"] == "true" {
+		arrow = "-.->"
+	}
+	_, err := fmt.Fprintf(r.w, "  %s %s %s\n", r.alias.get(from), arrow, r.alias.get(to))
+	return err
+}
+
+func (r *mermaidRenderer) EndGraph() error { return nil }
@@ -0,0 +1,105 @@
+// Package render turns a depgraph.Graph into one of several output formats.
+//
+// main.go used to hardcode DOT output with fmt.Printf calls interleaved
+// into the graph traversal. This package pulls that concern out behind a
+// Renderer interface so new formats are a new implementation, not a change
+// to how the graph is built.
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lotusirous/godepgraph/pkg/depgraph"
+)
+
+// Renderer streams a graph to an io.Writer one node and edge at a time.
+// Callers must call BeginGraph first and EndGraph last; WriteNode and
+// WriteEdge may be called any number of times in between.
+type Renderer interface {
+	BeginGraph() error
+	WriteNode(id, label string, attrs map[string]string) error
+	WriteEdge(from, to string, attrs map[string]string) error
+	EndGraph() error
+}
+
+// Options carries the rendering hints that come from CLI flags rather than
+// from the graph itself.
+type Options struct {
+	// Horizontal lays the graph out left-to-right instead of top-to-bottom,
+	// where the format supports it.
+	Horizontal bool
+
+	// Module is the root module path, included in formats (JSON) that
+	// describe the graph as a whole rather than just nodes and edges.
+	Module string
+}
+
+// byName looks up a format by name. The zero value of Options is a
+// reasonable default for every renderer.
+func byName(name string, w io.Writer, opts Options) (Renderer, error) {
+	switch name {
+	case "dot":
+		return newDOTRenderer(w, opts), nil
+	case "json":
+		return newJSONRenderer(w, opts), nil
+	case "mermaid":
+		return newMermaidRenderer(w, opts), nil
+	case "graphml":
+		return newGraphMLRenderer(w, opts), nil
+	case "d2":
+		return newD2Renderer(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+}
+
+// Write renders graph in the named format to w.
+func Write(w io.Writer, graph *depgraph.Graph, format string, opts Options) error {
+	r, err := byName(format, w, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := r.BeginGraph(); err != nil {
+		return err
+	}
+	for _, n := range graph.Nodes {
+		if err := r.WriteNode(n.ImportPath, n.ImportPath, nodeAttrs(n)); err != nil {
+			return err
+		}
+	}
+	for _, e := range graph.Edges {
+		if err := r.WriteEdge(e.From, e.To, edgeAttrs(e)); err != nil {
+			return err
+		}
+	}
+	return r.EndGraph()
+}
+
+func nodeAttrs(n depgraph.Node) map[string]string {
+	attrs := map[string]string{
+		"color":  n.Color,
+		"goroot": boolAttr(n.Goroot),
+		"cgo":    boolAttr(n.Cgo),
+	}
+	if n.Module != "" {
+		attrs["module"] = n.Module
+	}
+	if len(n.Errors) > 0 {
+		attrs["errors"] = strings.Join(n.Errors, "; ")
+	}
+	return attrs
+}
+
+func edgeAttrs(e depgraph.Edge) map[string]string {
+	return map[string]string{"test_only": boolAttr(e.TestOnly)}
+}
+
+func boolAttr(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// resolveModules figures out which modules godepgraph should treat as
+// local workspace members (highlighted with a per-module color) versus
+// required dependencies (highlighted with a single shared color), plus the
+// on-disk directory of every workspace member. dirs lets the graph cache
+// (see pkg/depgraph/cache.go) fingerprint every member's tree, not just
+// dir's — without it, editing a workspace sibling that dir never walks
+// would go unnoticed and serve a stale cached graph.
+//
+// If dir is inside a go.work workspace (found by searching dir and its
+// parents, the same way `go build` does, unless $GOWORK overrides or
+// disables that search; see findGoWork), every module listed in its "use"
+// directives is a workspace member, and the required modules are the union
+// of every member's go.mod "require" block. Otherwise dir's own go.mod is
+// the sole workspace member.
+func resolveModules(dir string) (required []string, workspace []string, dirs []string) {
+	workFile := findGoWork(dir)
+
+	if workFile == "" {
+		mod := mustParseModFile(filepath.Join(dir, "go.mod"))
+		return requiredOf(mod), []string{mod.Module.Mod.Path}, []string{dir}
+	}
+
+	data, err := os.ReadFile(workFile)
+	if err != nil {
+		die(err, "cannot read go.work")
+	}
+	work, err := modfile.ParseWork(workFile, data, nil)
+	if err != nil {
+		die(err, "failed to parse go.work")
+	}
+
+	workDir := filepath.Dir(workFile)
+	seen := make(map[string]bool)
+	for _, use := range work.Use {
+		memberDir := filepath.Join(workDir, use.Path)
+		mod := mustParseModFile(filepath.Join(memberDir, "go.mod"))
+		workspace = append(workspace, mod.Module.Mod.Path)
+		dirs = append(dirs, memberDir)
+		for _, r := range requiredOf(mod) {
+			if seen[r] {
+				continue
+			}
+			seen[r] = true
+			required = append(required, r)
+		}
+	}
+	return required, workspace, dirs
+}
+
+// findGoWork locates the go.work file that puts dir in workspace mode, the
+// same way cmd/go does: $GOWORK, if set, either names the file directly or,
+// as the literal value "off", disables workspace mode outright; otherwise
+// every directory from dir upward to the filesystem root is checked for a
+// go.work file, so running from inside a workspace member's own directory
+// still finds the workspace root's go.work.
+func findGoWork(dir string) string {
+	if gowork := os.Getenv("GOWORK"); gowork != "" {
+		if gowork == "off" {
+			return ""
+		}
+		return gowork
+	}
+	for d := dir; ; {
+		candidate := filepath.Join(d, "go.work")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return ""
+		}
+		d = parent
+	}
+}
+
+func requiredOf(mod *modfile.File) []string {
+	required := make([]string, 0, len(mod.Require))
+	for _, r := range mod.Require {
+		required = append(required, r.Mod.Path)
+	}
+	return required
+}
+
+func mustParseModFile(file string) *modfile.File {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		die(err, "cannot read go.mod")
+	}
+	mod, err := modfile.Parse(file, data, nil)
+	if err != nil {
+		die(err, "failed to parse mod file")
+	}
+	return mod
+}